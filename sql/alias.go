@@ -0,0 +1,180 @@
+package sql
+
+import (
+	"hash"
+	"io"
+	"sync"
+)
+
+// IndexAlias wraps one or more indexes behind a single logical name. It lets
+// a caller swap the underlying index (e.g. after a `CREATE INDEX ... REBUILD`)
+// without invalidating queries that are already holding a reference to the
+// alias, and lets an alias span several sharded indexes built for the same
+// expressions.
+type IndexAlias struct {
+	id string
+	db string
+
+	mut     sync.RWMutex
+	targets []Index
+}
+
+// NewIndexAlias creates a new, empty IndexAlias for the given database and
+// id. Targets are added with addTarget or replaced wholesale with Swap.
+func NewIndexAlias(db, id string) *IndexAlias {
+	return &IndexAlias{id: id, db: db}
+}
+
+// Swap atomically replaces all the targets of the alias with the given one.
+// Queries that already obtained an IndexLookup from the previous target are
+// unaffected; new lookups will be resolved against the new target.
+func (a *IndexAlias) Swap(target Index) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.targets = []Index{target}
+}
+
+// addTarget appends a new target to the alias, so it spans one more
+// underlying index.
+func (a *IndexAlias) addTarget(target Index) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.targets = append(a.targets, target)
+}
+
+// Targets returns the current list of indexes the alias points to.
+func (a *IndexAlias) Targets() []Index {
+	a.mut.RLock()
+	defer a.mut.RUnlock()
+
+	targets := make([]Index, len(a.targets))
+	copy(targets, a.targets)
+	return targets
+}
+
+// ID implements the Index interface.
+func (a *IndexAlias) ID() string { return a.id }
+
+// Database implements the Index interface.
+func (a *IndexAlias) Database() string { return a.db }
+
+// Table implements the Index interface. It returns the table of the first
+// target, since all targets of an alias are expected to index the same table
+// and expressions.
+func (a *IndexAlias) Table() string {
+	a.mut.RLock()
+	defer a.mut.RUnlock()
+
+	if len(a.targets) == 0 {
+		return ""
+	}
+	return a.targets[0].Table()
+}
+
+// ExpressionHashes implements the Index interface. It returns the expression
+// hashes of the first target.
+func (a *IndexAlias) ExpressionHashes() []hash.Hash {
+	a.mut.RLock()
+	defer a.mut.RUnlock()
+
+	if len(a.targets) == 0 {
+		return nil
+	}
+	return a.targets[0].ExpressionHashes()
+}
+
+// Get implements the Index interface. It fans the lookup out to every target
+// and merges the results.
+func (a *IndexAlias) Get(key interface{}) (IndexLookup, error) {
+	targets := a.Targets()
+
+	lookups := make([]IndexLookup, len(targets))
+	for i, t := range targets {
+		lookup, err := t.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		lookups[i] = lookup
+	}
+
+	return newAliasLookup(lookups...), nil
+}
+
+// Has implements the Index interface. It returns true as soon as one of the
+// targets has the key.
+func (a *IndexAlias) Has(key interface{}) (bool, error) {
+	for _, t := range a.Targets() {
+		ok, err := t.Has(key)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// aliasLookup merges the IndexValueIter of several IndexLookups, deduplicating
+// repo locations, so that an alias spanning several sharded indexes behaves
+// like a single IndexLookup to its callers.
+type aliasLookup struct {
+	lookups []IndexLookup
+}
+
+func newAliasLookup(lookups ...IndexLookup) *aliasLookup {
+	return &aliasLookup{lookups: lookups}
+}
+
+// Values implements the IndexLookup interface.
+func (l *aliasLookup) Values() IndexValueIter {
+	return &aliasValueIter{lookups: l.lookups, seen: make(map[string]struct{})}
+}
+
+type aliasValueIter struct {
+	lookups []IndexLookup
+	current IndexValueIter
+	seen    map[string]struct{}
+}
+
+// Next implements the IndexValueIter interface. It drains each underlying
+// lookup in turn, skipping repo locations already returned by a previous one.
+func (i *aliasValueIter) Next() ([]byte, error) {
+	for {
+		if i.current == nil {
+			if len(i.lookups) == 0 {
+				return nil, io.EOF
+			}
+			i.current = i.lookups[0].Values()
+			i.lookups = i.lookups[1:]
+		}
+
+		value, err := i.current.Next()
+		if err == io.EOF {
+			if err := i.current.Close(); err != nil {
+				return nil, err
+			}
+			i.current = nil
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		key := string(value)
+		if _, ok := i.seen[key]; ok {
+			continue
+		}
+		i.seen[key] = struct{}{}
+
+		return value, nil
+	}
+}
+
+// Close implements the IndexValueIter interface.
+func (i *aliasValueIter) Close() error {
+	if i.current != nil {
+		return i.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package sql
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type sliceLookup struct {
+	values [][]byte
+}
+
+func (l *sliceLookup) Values() IndexValueIter {
+	return &sliceValueIter{values: l.values}
+}
+
+type sliceValueIter struct {
+	values [][]byte
+	pos    int
+}
+
+func (i *sliceValueIter) Next() ([]byte, error) {
+	if i.pos >= len(i.values) {
+		return nil, io.EOF
+	}
+	v := i.values[i.pos]
+	i.pos++
+	return v, nil
+}
+
+func (i *sliceValueIter) Close() error { return nil }
+
+func drainValues(t *testing.T, iter IndexValueIter) [][]byte {
+	t.Helper()
+	var got [][]byte
+	for {
+		v, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, v)
+	}
+	require.NoError(t, iter.Close())
+	return got
+}
+
+func TestAliasLookupMergeDedup(t *testing.T) {
+	require := require.New(t)
+
+	a := &sliceLookup{values: [][]byte{[]byte("a"), []byte("b"), []byte("c")}}
+	b := &sliceLookup{values: [][]byte{[]byte("b"), []byte("c"), []byte("d")}}
+
+	merged := newAliasLookup(a, b)
+	got := drainValues(t, merged.Values())
+
+	require.Equal([][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}, got)
+}
+
+func TestAliasLookupSingleTarget(t *testing.T) {
+	require := require.New(t)
+
+	a := &sliceLookup{values: [][]byte{[]byte("x"), []byte("y")}}
+
+	merged := newAliasLookup(a)
+	got := drainValues(t, merged.Values())
+
+	require.Equal([][]byte{[]byte("x"), []byte("y")}, got)
+}
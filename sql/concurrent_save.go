@@ -0,0 +1,315 @@
+package sql
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// KeyValue is a single tuple read off an IndexKeyValueIter, grouped into
+// batches for BatchIndexer.
+type KeyValue struct {
+	// Values are the indexed column values.
+	Values []interface{}
+	// Location is the repo location the values were read from.
+	Location []byte
+}
+
+// BatchIndexer is implemented by Index implementations that can index
+// several key/value tuples in one call. ConcurrentSave uses it to fan a key
+// stream out across a worker pool instead of indexing one tuple at a time.
+type BatchIndexer interface {
+	// IndexBatch indexes every tuple in batch.
+	IndexBatch(batch []KeyValue) error
+}
+
+// ProgressReporter is called periodically during a concurrent index build to
+// report how many rows and bytes have been indexed so far, so long builds on
+// large tables are observable.
+type ProgressReporter func(rowsIndexed, bytesIndexed uint64)
+
+// DefaultIndexBatchSize is the number of key/value tuples grouped into a
+// single batch handed to a BatchIndexer worker.
+const DefaultIndexBatchSize = 1024
+
+// DefaultMaxIndexBuildWorkers is the worker pool size ConcurrentSave uses
+// when callers don't request a specific one, analogous to
+// concurrency.ForEachJob in dskit.
+func DefaultMaxIndexBuildWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// ConcurrentSave drains iter into batches of batchSize tuples and indexes
+// them using workers goroutines calling indexer.IndexBatch, instead of
+// processing the key stream serially. A non-positive workers or batchSize
+// falls back to DefaultMaxIndexBuildWorkers and DefaultIndexBatchSize. If
+// progress is non-nil, it's called after every batch with the cumulative
+// rows and bytes indexed so far. The first error from the reader goroutine
+// or any worker cancels ctx and is returned; iter is always closed.
+func ConcurrentSave(ctx context.Context, indexer BatchIndexer, iter IndexKeyValueIter, workers, batchSize int, progress ProgressReporter) error {
+	return concurrentSave(ctx, indexer, iter, workers, batchSize, progress, nil)
+}
+
+// ConcurrentSaveResumable behaves like ConcurrentSave, additionally calling
+// checkpoint with the repo location of the last tuple of every batch, once
+// that batch and every batch read before it have had IndexBatch return
+// successfully. Because workers finish batches out of order, a batch's
+// location is only handed to checkpoint once it's the oldest one still
+// outstanding, so checkpoint never advances past a batch that hasn't
+// actually been indexed yet.
+func ConcurrentSaveResumable(ctx context.Context, indexer BatchIndexer, iter IndexKeyValueIter, workers, batchSize int, progress ProgressReporter, checkpoint func(location []byte) error) error {
+	return concurrentSave(ctx, indexer, iter, workers, batchSize, progress, checkpoint)
+}
+
+// sequencedBatch tags a batch with the order it was read off iter in, so
+// checkpoint can be called only once every earlier batch has finished.
+type sequencedBatch struct {
+	seq   uint64
+	batch []KeyValue
+}
+
+func concurrentSave(ctx context.Context, indexer BatchIndexer, iter IndexKeyValueIter, workers, batchSize int, progress ProgressReporter, checkpoint func(location []byte) error) error {
+	defer iter.Close()
+
+	if workers <= 0 {
+		workers = DefaultMaxIndexBuildWorkers()
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultIndexBatchSize
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+	batches := make(chan sequencedBatch, workers)
+
+	group.Go(func() error {
+		defer close(batches)
+
+		var seq uint64
+		batch := make([]KeyValue, 0, batchSize)
+
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+
+			select {
+			case batches <- sequencedBatch{seq: seq, batch: batch}:
+				seq++
+				batch = make([]KeyValue, 0, batchSize)
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		for {
+			values, location, err := iter.Next()
+			if err == io.EOF {
+				return flush()
+			}
+			if err != nil {
+				return err
+			}
+
+			batch = append(batch, KeyValue{Values: values, Location: location})
+			if len(batch) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	})
+
+	var mu sync.Mutex
+	var rows, bytesIndexed uint64
+
+	var cpMu sync.Mutex
+	var nextSeq uint64
+	pendingCheckpoints := make(map[uint64][]byte)
+
+	// advanceCheckpoint records the last location of a just-finished batch
+	// and calls checkpoint for it, and for every subsequent batch already
+	// finished, as soon as they form an unbroken run starting at nextSeq.
+	// A batch that finished early but out of order (e.g. seq 3 before seq
+	// 2) is held in pendingCheckpoints until seq 2 finishes too.
+	advanceCheckpoint := func(seq uint64, lastLocation []byte) error {
+		if checkpoint == nil {
+			return nil
+		}
+
+		cpMu.Lock()
+		defer cpMu.Unlock()
+
+		pendingCheckpoints[seq] = lastLocation
+		for {
+			location, ok := pendingCheckpoints[nextSeq]
+			if !ok {
+				return nil
+			}
+
+			delete(pendingCheckpoints, nextSeq)
+			nextSeq++
+
+			if err := checkpoint(location); err != nil {
+				return err
+			}
+		}
+	}
+
+	for n := 0; n < workers; n++ {
+		group.Go(func() error {
+			for sb := range batches {
+				if err := indexer.IndexBatch(sb.batch); err != nil {
+					return err
+				}
+
+				if progress != nil {
+					mu.Lock()
+					rows += uint64(len(sb.batch))
+					bytesIndexed += batchBytes(sb.batch)
+					progress(rows, bytesIndexed)
+					mu.Unlock()
+				}
+
+				if len(sb.batch) > 0 {
+					if err := advanceCheckpoint(sb.seq, sb.batch[len(sb.batch)-1].Location); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+
+	return group.Wait()
+}
+
+func batchBytes(batch []KeyValue) uint64 {
+	var n uint64
+	for _, kv := range batch {
+		n += uint64(len(kv.Location))
+		for _, v := range kv.Values {
+			if s, ok := v.(string); ok {
+				n += uint64(len(s))
+			}
+		}
+	}
+	return n
+}
+
+// ConcurrentIndexDriver is implemented by IndexDriver implementations that
+// support building an index with a bounded worker pool instead of a single
+// serial pass, and resuming a build that crashed partway through.
+type ConcurrentIndexDriver interface {
+	IndexDriver
+
+	// SaveWithProgress behaves like Save, additionally invoking progress
+	// periodically so long builds on large tables are observable.
+	SaveWithProgress(ctx context.Context, index Index, iter IndexKeyValueIter, progress ProgressReporter) error
+
+	// SaveResumable behaves like SaveWithProgress, additionally calling
+	// checkpoint once a batch - and every batch read before it - has been
+	// durably indexed, so the registry can persist a resume point that a
+	// crashed build can pick back up from. See ConcurrentSaveResumable.
+	SaveResumable(ctx context.Context, index Index, iter IndexKeyValueIter, progress ProgressReporter, checkpoint func(location []byte) error) error
+}
+
+// checkpointDir is the directory, relative to IndexRegistry.Root, that
+// SaveResumable persists its checkpoints under.
+const checkpointDir = "checkpoints"
+
+func (r *IndexRegistry) checkpointPath(idx Index) string {
+	return filepath.Join(r.Root, checkpointDir, idx.Database()+"."+idx.ID())
+}
+
+// checkpoint returns the repo location of the last batch fully indexed for
+// idx in a previous, interrupted SaveResumable call, or nil if there isn't
+// one.
+func (r *IndexRegistry) checkpoint(idx Index) ([]byte, error) {
+	data, err := ioutil.ReadFile(r.checkpointPath(idx))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (r *IndexRegistry) saveCheckpoint(idx Index, location []byte) error {
+	path := r.checkpointPath(idx)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, location, 0644)
+}
+
+func (r *IndexRegistry) clearCheckpoint(idx Index) error {
+	err := os.Remove(r.checkpointPath(idx))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// SaveResumable builds idx using driver, checkpointing the repo location of
+// the last batch that was actually, successfully indexed under
+// Root/checkpoints/, so that, if the build crashes partway through, a later
+// call resumes from there instead of starting over. If driver doesn't
+// implement ConcurrentIndexDriver, this falls back to a plain driver.Save.
+func (r *IndexRegistry) SaveResumable(ctx context.Context, driver IndexDriver, idx Index, iter IndexKeyValueIter, progress ProgressReporter) error {
+	cd, ok := driver.(ConcurrentIndexDriver)
+	if !ok {
+		return driver.Save(ctx, idx, iter)
+	}
+
+	after, err := r.checkpoint(idx)
+	if err != nil {
+		return err
+	}
+	if after != nil {
+		iter = &resumedKeyValueIter{IndexKeyValueIter: iter, skipUntil: after}
+	}
+
+	checkpoint := func(location []byte) error {
+		return r.saveCheckpoint(idx, location)
+	}
+
+	if err := cd.SaveResumable(ctx, idx, iter, progress, checkpoint); err != nil {
+		return err
+	}
+
+	return r.clearCheckpoint(idx)
+}
+
+// resumedKeyValueIter skips every tuple up to and including the one at
+// skipUntil, so a resumed build doesn't re-index work a previous,
+// interrupted run already completed. Since skipUntil only ever comes from a
+// checkpoint written after that tuple's batch was fully, successfully
+// indexed, it's always safe to skip.
+type resumedKeyValueIter struct {
+	IndexKeyValueIter
+	skipUntil []byte
+	skipped   bool
+}
+
+func (i *resumedKeyValueIter) Next() ([]interface{}, []byte, error) {
+	if i.skipped {
+		return i.IndexKeyValueIter.Next()
+	}
+
+	for {
+		_, location, err := i.IndexKeyValueIter.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if string(location) == string(i.skipUntil) {
+			i.skipped = true
+			return i.IndexKeyValueIter.Next()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKeyValueIter struct {
+	locations [][]byte
+	pos       int
+	closed    bool
+}
+
+func (i *fakeKeyValueIter) Next() ([]interface{}, []byte, error) {
+	if i.pos >= len(i.locations) {
+		return nil, nil, io.EOF
+	}
+	loc := i.locations[i.pos]
+	i.pos++
+	return []interface{}{string(loc)}, loc, nil
+}
+
+func (i *fakeKeyValueIter) Close() error {
+	i.closed = true
+	return nil
+}
+
+type fakeBatchIndexer struct {
+	mu      sync.Mutex
+	indexed int
+	failAt  int
+}
+
+func (f *fakeBatchIndexer) IndexBatch(batch []KeyValue) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failAt > 0 && f.indexed+len(batch) >= f.failAt {
+		return errors.New("boom")
+	}
+	f.indexed += len(batch)
+	return nil
+}
+
+func testLocations(n int) [][]byte {
+	locations := make([][]byte, n)
+	for i := range locations {
+		locations[i] = []byte(fmt.Sprintf("loc-%04d", i))
+	}
+	return locations
+}
+
+func TestConcurrentSave(t *testing.T) {
+	require := require.New(t)
+
+	locations := testLocations(500)
+	iter := &fakeKeyValueIter{locations: locations}
+	indexer := &fakeBatchIndexer{}
+
+	err := ConcurrentSave(context.Background(), indexer, iter, 4, 7, nil)
+	require.NoError(err)
+	require.Equal(len(locations), indexer.indexed)
+	require.True(iter.closed)
+}
+
+func TestConcurrentSavePropagatesIndexError(t *testing.T) {
+	require := require.New(t)
+
+	locations := testLocations(50)
+	iter := &fakeKeyValueIter{locations: locations}
+	indexer := &fakeBatchIndexer{failAt: 10}
+
+	err := ConcurrentSave(context.Background(), indexer, iter, 4, 5, nil)
+	require.Error(err)
+}
+
+// TestConcurrentSaveResumableCheckpointOrder builds with batchSize 1 so each
+// worker checkpoints a single location at a time; regardless of which worker
+// finishes which batch first, checkpoint must only ever be called with
+// locations in the exact order they were read off iter, and every location
+// exactly once, since a later batch can't be durable before an earlier one.
+func TestConcurrentSaveResumableCheckpointOrder(t *testing.T) {
+	require := require.New(t)
+
+	locations := testLocations(200)
+	iter := &fakeKeyValueIter{locations: locations}
+	indexer := &fakeBatchIndexer{}
+
+	var mu sync.Mutex
+	var checkpoints [][]byte
+
+	err := ConcurrentSaveResumable(context.Background(), indexer, iter, 8, 1, nil, func(location []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		checkpoints = append(checkpoints, append([]byte(nil), location...))
+		return nil
+	})
+
+	require.NoError(err)
+	require.Equal(locations, checkpoints)
+}
@@ -0,0 +1,117 @@
+package sql
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// FieldDict is implemented by indexes that keep their terms in a sorted
+// structure (a B-tree, an LSM, a radix tree, ...) and can therefore expose
+// an ordered scan over the term dictionary of an indexed expression, instead
+// of only point lookups. Indexes that don't are not required to implement
+// it; callers should treat a failed type assertion the same as
+// ErrFieldDictNotSupported.
+type FieldDict interface {
+	// FieldDict returns an iterator over every term indexed for expr, in
+	// sorted order.
+	FieldDict(expr Expression) (FieldDictIter, error)
+	// FieldDictRange returns an iterator over the terms indexed for expr
+	// that are in the range [start, end).
+	FieldDictRange(expr Expression, start, end []byte) (FieldDictIter, error)
+	// FieldDictPrefix returns an iterator over the terms indexed for expr
+	// that start with prefix.
+	FieldDictPrefix(expr Expression, prefix []byte) (FieldDictIter, error)
+}
+
+// FieldDictIter iterates over the sorted term dictionary of an indexed
+// expression.
+type FieldDictIter interface {
+	// Next returns the next term in sorted order, along with the number of
+	// documents (repo locations) it appears in.
+	Next() (term []byte, docCount uint64, err error)
+	io.Closer
+}
+
+// ErrFieldDictNotSupported is returned when FieldDict lookups are attempted
+// against an index that doesn't keep its terms in a sorted structure.
+var ErrFieldDictNotSupported = errors.NewKind("index %q does not support field dictionary lookups")
+
+// retainedFieldDictIter wraps a FieldDictIter so that it holds a reference on
+// its index for as long as it's open, the same way an IndexValueIter does,
+// so an in-flight dictionary scan blocks DeleteIndex until it's closed.
+type retainedFieldDictIter struct {
+	FieldDictIter
+	registry *IndexRegistry
+	idx      Index
+	closed   bool
+}
+
+// Close implements FieldDictIter.
+func (i *retainedFieldDictIter) Close() error {
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	defer i.registry.ReleaseIndex(i.idx)
+	return i.FieldDictIter.Close()
+}
+
+func (r *IndexRegistry) fieldDict(idx Index) (FieldDict, error) {
+	fd, ok := idx.(FieldDict)
+	if !ok {
+		return nil, ErrFieldDictNotSupported.New(idx.ID())
+	}
+	return fd, nil
+}
+
+func (r *IndexRegistry) retainFieldDictIter(idx Index, iter FieldDictIter, err error) (FieldDictIter, error) {
+	if err != nil {
+		r.ReleaseIndex(idx)
+		return nil, err
+	}
+	return &retainedFieldDictIter{FieldDictIter: iter, registry: r, idx: idx}, nil
+}
+
+// FieldDict returns a FieldDictIter over every term of expr indexed by idx.
+// The iterator retains a reference on idx until it's closed, so it blocks a
+// concurrent DeleteIndex the same way an IndexValueIter does.
+func (r *IndexRegistry) FieldDict(idx Index, expr Expression) (FieldDictIter, error) {
+	fd, err := r.fieldDict(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.retainIndex(idx.Database(), idx.ID())
+	iter, err := fd.FieldDict(expr)
+	return r.retainFieldDictIter(idx, iter, err)
+}
+
+// FieldDictRange returns a FieldDictIter over the terms of expr indexed by
+// idx that fall in [start, end). See FieldDict for the refcount protocol.
+func (r *IndexRegistry) FieldDictRange(idx Index, expr Expression, start, end []byte) (FieldDictIter, error) {
+	fd, err := r.fieldDict(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.retainIndex(idx.Database(), idx.ID())
+	iter, err := fd.FieldDictRange(expr, start, end)
+	return r.retainFieldDictIter(idx, iter, err)
+}
+
+// FieldDictPrefix returns a FieldDictIter over the terms of expr indexed by
+// idx that start with prefix. plan.PushdownFieldDictPrefix and
+// plan.PushdownMatchAgainst answer LIKE 'prefix%' and MATCH ... AGAINST
+// predicates on an indexed string column with it; nothing in the planner
+// calls those helpers. See FieldDict for the refcount protocol.
+func (r *IndexRegistry) FieldDictPrefix(idx Index, expr Expression, prefix []byte) (FieldDictIter, error) {
+	fd, err := r.fieldDict(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.retainIndex(idx.Database(), idx.ID())
+	iter, err := fd.FieldDictPrefix(expr, prefix)
+	return r.retainFieldDictIter(idx, iter, err)
+}
@@ -2,12 +2,12 @@ package sql
 
 import (
 	"context"
-	"fmt"
 	"hash"
 	"io"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"gopkg.in/src-d/go-errors.v1"
 )
@@ -110,7 +110,9 @@ type IndexDriver interface {
 	ID() string
 	// Create a new index. If exprs is more than one expression, it means the
 	// index has multiple columns indexed. If it's just one, it means it may
-	// be an expression or a column.
+	// be an expression or a column. config is driver-specific and is parsed
+	// by each driver into its own options (e.g. analyzer choice for bleve,
+	// shard count for pilosa, host URL for elasticsearch).
 	Create(db, table, id string, expressionHashes []hash.Hash, config map[string]string) (Index, error)
 	// Load the index at the given path.
 	Load(db, table string) ([]Index, error)
@@ -118,6 +120,10 @@ type IndexDriver interface {
 	Save(ctx context.Context, index Index, iter IndexKeyValueIter) error
 	// Delete the index with the given path.
 	Delete(index Index) error
+	// SchemaVersion returns the version of the on-disk/remote schema this
+	// driver reads and writes. Load should refuse to load an index written
+	// by an incompatible version instead of returning corrupt results.
+	SchemaVersion() uint32
 }
 
 type indexKey struct {
@@ -125,13 +131,19 @@ type indexKey struct {
 }
 
 // IndexRegistry keeps track of all indexes in the engine.
+//
+// Index definitions are published as an immutable registrySnapshot behind
+// published, an atomic.Value. Reads (Index, IndexByExpression, CanUseIndex)
+// load the current snapshot without taking a lock. Writes (AddIndex,
+// DeleteIndex, AddAlias) go through a RegistryTxn, which copies the published
+// snapshot, applies the mutation, and publishes the result atomically; mut
+// serializes transactions so at most one write is in flight at a time.
 type IndexRegistry struct {
 	// Root path where all the data of the indexes is stored on disk.
 	Root string
 
-	mut      sync.RWMutex
-	indexes  map[indexKey]Index
-	statuses map[indexKey]IndexStatus
+	mut       sync.Mutex
+	published atomic.Value // *registrySnapshot
 
 	driversMut sync.RWMutex
 	drivers    map[string]IndexDriver
@@ -143,13 +155,24 @@ type IndexRegistry struct {
 
 // NewIndexRegistry returns a new Index Registry.
 func NewIndexRegistry() *IndexRegistry {
-	return &IndexRegistry{
-		indexes:          make(map[indexKey]Index),
-		statuses:         make(map[indexKey]IndexStatus),
+	r := &IndexRegistry{
 		drivers:          make(map[string]IndexDriver),
 		refCounts:        make(map[indexKey]int),
 		deleteIndexQueue: make(map[indexKey]chan<- struct{}),
 	}
+	r.published.Store(newRegistrySnapshot())
+	return r
+}
+
+// snapshot returns the currently published registrySnapshot.
+func (r *IndexRegistry) snapshot() *registrySnapshot {
+	return r.published.Load().(*registrySnapshot)
+}
+
+// publish makes s the currently published registrySnapshot. It must only be
+// called by a RegistryTxn while holding mut.
+func (r *IndexRegistry) publish(s *registrySnapshot) {
+	r.published.Store(s)
 }
 
 // IndexDriver returns the IndexDriver with the given ID.
@@ -175,14 +198,8 @@ func (r *IndexRegistry) retainIndex(db, id string) {
 
 // CanUseIndex returns whether the given index is ready to use or not.
 func (r *IndexRegistry) CanUseIndex(idx Index) bool {
-	r.mut.RLock()
-	defer r.mut.RUnlock()
-	return bool(r.statuses[indexKey{idx.Database(), idx.ID()}])
-}
-
-// setStatus is not thread-safe, it should be guarded using mut.
-func (r *IndexRegistry) setStatus(idx Index, status IndexStatus) {
-	r.statuses[indexKey{idx.Database(), idx.ID()}] = status
+	s := r.snapshot()
+	return bool(s.statuses[indexKey{idx.Database(), idx.ID()}])
 }
 
 // ReleaseIndex releases an index after it's been used.
@@ -205,24 +222,22 @@ func (r *IndexRegistry) ReleaseIndex(idx Index) {
 // Index returns the index with the given id. It may return nil if the index is
 // not found.
 func (r *IndexRegistry) Index(db, id string) Index {
-	r.mut.RLock()
-	defer r.mut.RUnlock()
-	return r.indexes[indexKey{db, strings.ToLower(id)}]
+	s := r.snapshot()
+	return s.indexes[indexKey{db, strings.ToLower(id)}]
 }
 
 // IndexByExpression returns an index by the given expression. It will return
 // nil it the index is not found. If more than one expression is given, all
 // of them must match for the index to be matched.
 func (r *IndexRegistry) IndexByExpression(db string, expr ...Expression) Index {
-	r.mut.RLock()
-	defer r.mut.RUnlock()
+	s := r.snapshot()
 
 	var expressionHashes []hash.Hash
 	for _, e := range expr {
 		expressionHashes = append(expressionHashes, NewExpressionHash(e))
 	}
 
-	for _, idx := range r.indexes {
+	for _, idx := range s.indexes {
 		if idx.Database() == db {
 			if exprListsEqual(idx.ExpressionHashes(), expressionHashes) {
 				r.retainIndex(db, idx.ID())
@@ -251,31 +266,6 @@ var (
 	ErrIndexDeleteInvalidStatus = errors.NewKind("can't delete index %q because it's not ready for usage")
 )
 
-func (r *IndexRegistry) validateIndexToAdd(idx Index) error {
-	r.mut.RLock()
-	defer r.mut.RUnlock()
-
-	for _, i := range r.indexes {
-		if i.Database() != idx.Database() {
-			continue
-		}
-
-		if i.ID() == idx.ID() {
-			return ErrIndexIDAlreadyRegistered.New(idx.ID())
-		}
-
-		if exprListsEqual(i.ExpressionHashes(), idx.ExpressionHashes()) {
-			var exprs = make([]string, len(idx.ExpressionHashes()))
-			for i, e := range idx.ExpressionHashes() {
-				exprs[i] = fmt.Sprintf("%x", e.Sum(nil))
-			}
-			return ErrIndexExpressionAlreadyRegistered.New(strings.Join(exprs, ", "))
-		}
-	}
-
-	return nil
-}
-
 func exprListsEqual(a, b []hash.Hash) bool {
 	var visited = make([]bool, len(b))
 	for _, va := range a {
@@ -306,73 +296,98 @@ func exprListsEqual(a, b []hash.Hash) bool {
 // When something is sent through the returned channel, it means the index has
 // finished it's creation and will be marked as ready.
 func (r *IndexRegistry) AddIndex(idx Index) (chan<- struct{}, error) {
-	if err := r.validateIndexToAdd(idx); err != nil {
+	txn := r.Txn()
+	if err := txn.AddIndex(idx); err != nil {
+		txn.Abort()
 		return nil, err
 	}
-
-	r.mut.Lock()
-	r.setStatus(idx, IndexNotReady)
-	r.indexes[indexKey{idx.Database(), idx.ID()}] = idx
-	r.mut.Unlock()
+	txn.Commit()
 
 	var created = make(chan struct{})
 	go func() {
 		<-created
-		r.mut.Lock()
-		defer r.mut.Unlock()
-		r.setStatus(idx, IndexReady)
+
+		txn := r.Txn()
+		txn.MarkReady(idx)
+		txn.Commit()
 	}()
 
 	return created, nil
 }
 
+// AddAlias registers target under the given alias name, creating the alias
+// if it doesn't exist yet or adding target as one more shard of it otherwise.
+// IndexByExpression and Index resolve the alias transparently, so swapping
+// its target with IndexAlias.Swap lets a freshly rebuilt index replace a
+// stale one without invalidating queries that are already in flight.
+func (r *IndexRegistry) AddAlias(db, alias string, target Index) error {
+	txn := r.Txn()
+	if err := txn.addAlias(db, alias, target); err != nil {
+		txn.Abort()
+		return err
+	}
+	txn.Commit()
+
+	return nil
+}
+
 // DeleteIndex deletes an index from the registry by its id. First, it marks
 // the index for deletion but does not remove it, so queries that are using it
 // may still do so. The returned channel will send a message when the index can
 // be deleted from disk.
 func (r *IndexRegistry) DeleteIndex(db, id string) (<-chan struct{}, error) {
-	r.mut.RLock()
+	// The usability check and the deleteIndexQueue registration below must
+	// happen as one atomic step: otherwise two concurrent DeleteIndex calls
+	// for the same index can both observe it as usable before either marks
+	// it IndexNotReady, and then both register a deleteIndexQueue entry, with
+	// the second silently overwriting - and leaking - the first's channel.
+	r.rcmut.Lock()
+	defer r.rcmut.Unlock()
+
+	s := r.snapshot()
 	var key indexKey
-	for k, idx := range r.indexes {
-		if strings.ToLower(id) == idx.ID() {
-			if !r.CanUseIndex(idx) {
-				r.mut.RUnlock()
+	var found bool
+	for k, idx := range s.indexes {
+		if k.db == db && strings.ToLower(id) == idx.ID() {
+			if !s.statuses[k].IsUsable() || r.deleteIndexQueue[k] != nil {
 				return nil, ErrIndexDeleteInvalidStatus.New(id)
 			}
-			r.setStatus(idx, IndexNotReady)
-			key = k
+			key, found = k, true
 			break
 		}
 	}
-	r.mut.RUnlock()
 
-	if key.id == "" {
+	if !found {
 		return nil, ErrIndexNotFound.New(id)
 	}
 
+	txn := r.Txn()
+	txn.work.statuses[key] = IndexNotReady
+	txn.Commit()
+
 	var done = make(chan struct{}, 1)
 
-	r.rcmut.Lock()
-	// If no query is using this index just delete it right away
+	// If no query is using this index just delete it right away.
 	if r.refCounts[key] == 0 {
-		r.mut.Lock()
-		defer r.mut.Unlock()
-		defer r.rcmut.Unlock()
+		txn := r.Txn()
+		delete(txn.work.indexes, key)
+		delete(txn.work.statuses, key)
+		txn.Commit()
 
-		delete(r.indexes, key)
 		close(done)
 		return done, nil
 	}
 
 	var onReadyToDelete = make(chan struct{})
 	r.deleteIndexQueue[key] = onReadyToDelete
-	r.rcmut.Unlock()
 
 	go func() {
 		<-onReadyToDelete
-		r.mut.Lock()
-		defer r.mut.Unlock()
-		delete(r.indexes, key)
+
+		txn := r.Txn()
+		delete(txn.work.indexes, key)
+		delete(txn.work.statuses, key)
+		txn.Commit()
 
 		done <- struct{}{}
 	}()
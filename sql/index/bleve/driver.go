@@ -0,0 +1,191 @@
+// Package bleve implements a sql.IndexDriver backed by a local bleve
+// inverted index, suitable for text search over string columns.
+package bleve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"path/filepath"
+
+	"github.com/blevesearch/bleve"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/index/internal"
+)
+
+// DriverID is the unique name of this driver, as registered with
+// sql.IndexRegistry.RegisterIndexDriver.
+const DriverID = "bleve"
+
+// schemaVersion is bumped whenever the on-disk layout written by this driver
+// changes in a way older versions of the driver can't read back.
+const schemaVersion = 1
+
+// Config are the bleve-specific options parsed out of the config map passed
+// to Driver.Create.
+type Config struct {
+	// Analyzer is the name of the bleve text analyzer to use for indexed
+	// fields (e.g. "standard", "en", "keyword"). Defaults to "standard".
+	Analyzer string
+}
+
+func newConfig(config map[string]string) Config {
+	analyzer := config["analyzer"]
+	if analyzer == "" {
+		analyzer = "standard"
+	}
+	return Config{Analyzer: analyzer}
+}
+
+// Driver is a sql.IndexDriver that stores indexes as bleve inverted indexes
+// on local disk.
+type Driver struct {
+	Root string
+
+	// MaxWorkers bounds the worker pool Save uses to index batches
+	// concurrently. Zero means sql.DefaultMaxIndexBuildWorkers.
+	MaxWorkers int
+	// BatchSize is the number of key/value tuples grouped into a single
+	// bleve batch. Zero means sql.DefaultIndexBatchSize.
+	BatchSize int
+}
+
+var _ sql.ConcurrentIndexDriver = (*Driver)(nil)
+
+// NewDriver returns a new bleve Driver storing its indexes under root.
+func NewDriver(root string) *Driver {
+	return &Driver{Root: root}
+}
+
+// ID implements sql.IndexDriver.
+func (*Driver) ID() string { return DriverID }
+
+// SchemaVersion implements sql.IndexDriver.
+func (*Driver) SchemaVersion() uint32 { return schemaVersion }
+
+func (d *Driver) path(db, id string) string {
+	return filepath.Join(d.Root, db, id+".bleve")
+}
+
+// metaKey is the internal bleve key the driver's meta is stored under, read
+// and written through metaEngine.
+const metaKey = "gms_meta"
+
+// Create implements sql.IndexDriver.
+func (d *Driver) Create(db, table, id string, expressionHashes []hash.Hash, config map[string]string) (sql.Index, error) {
+	cfg := newConfig(config)
+
+	mapping := bleve.NewIndexMapping()
+	mapping.DefaultAnalyzer = cfg.Analyzer
+
+	path := d.path(db, id)
+	bidx, err := bleve.New(path, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &metaEngine{bidx: bidx}
+	m := meta{SchemaVersion: schemaVersion, Database: db, Table: table, Config: cfg}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := engine.Save(metaKey, raw); err != nil {
+		return nil, err
+	}
+
+	return &index{
+		db:               db,
+		table:            table,
+		id:               id,
+		expressionHashes: expressionHashes,
+		config:           cfg,
+		bleve:            bidx,
+	}, nil
+}
+
+type meta struct {
+	SchemaVersion uint32 `json:"schema_version"`
+	Database      string `json:"database"`
+	Table         string `json:"table"`
+	Config        Config `json:"config"`
+}
+
+// Load implements sql.IndexDriver.
+func (d *Driver) Load(db, table string) ([]sql.Index, error) {
+	path := d.path(db, table)
+
+	bidx, err := bleve.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &metaEngine{bidx: bidx}
+	if err := engine.Init(path); err != nil {
+		return nil, err
+	}
+
+	raw, err := engine.Load(metaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var m meta
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.SchemaVersion != 0 && m.SchemaVersion != schemaVersion {
+		return nil, internal.ErrIncompatibleSchemaVersion.New(
+			fmt.Sprintf("%s/%s", db, table), m.SchemaVersion, DriverID, schemaVersion,
+		)
+	}
+
+	return []sql.Index{&index{
+		db:     m.Database,
+		table:  m.Table,
+		id:     table,
+		config: m.Config,
+		bleve:  bidx,
+	}}, nil
+}
+
+// Save implements sql.IndexDriver. It indexes the key stream concurrently
+// across a worker pool, see sql.ConcurrentSave.
+func (d *Driver) Save(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter) error {
+	return d.SaveWithProgress(ctx, idx, iter, nil)
+}
+
+// SaveWithProgress implements sql.ConcurrentIndexDriver.
+func (d *Driver) SaveWithProgress(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter, progress sql.ProgressReporter) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("bleve driver can't save index of type %T", idx)
+	}
+	return sql.ConcurrentSave(ctx, i, iter, d.MaxWorkers, d.BatchSize, progress)
+}
+
+// SaveResumable implements sql.ConcurrentIndexDriver. Skipping already-
+// indexed tuples on resume is handled by IndexRegistry.SaveResumable before
+// iter reaches here; checkpoint is called once a batch - and every batch
+// read before it - has actually finished indexing.
+func (d *Driver) SaveResumable(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter, progress sql.ProgressReporter, checkpoint func(location []byte) error) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("bleve driver can't save index of type %T", idx)
+	}
+	return sql.ConcurrentSaveResumable(ctx, i, iter, d.MaxWorkers, d.BatchSize, progress, checkpoint)
+}
+
+// Delete implements sql.IndexDriver.
+func (d *Driver) Delete(idx sql.Index) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("bleve driver can't delete index of type %T", idx)
+	}
+	return i.bleve.Close()
+}
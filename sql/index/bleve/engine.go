@@ -0,0 +1,53 @@
+package bleve
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql/index/internal"
+)
+
+// metaEngine adapts an already-open bleve.Index's internal key/value store
+// to the internal.Engine storage contract, so the driver's schema-version
+// metadata is read and written through internal.Engine, instead of calling
+// bidx.GetInternal/SetInternal directly.
+type metaEngine struct {
+	bidx bleve.Index
+}
+
+var _ internal.Engine = (*metaEngine)(nil)
+
+// Init implements internal.Engine. The underlying bleve.Index is already
+// open by the time Driver.Create or Driver.Load constructs a metaEngine
+// around it, so Init only checks it's actually there.
+func (e *metaEngine) Init(path string) error {
+	if e.bidx == nil {
+		return fmt.Errorf("bleve metaEngine: no index open at %q", path)
+	}
+	return nil
+}
+
+// Ping implements internal.Engine.
+func (e *metaEngine) Ping() error {
+	_, err := e.bidx.DocCount()
+	return err
+}
+
+// Close implements internal.Engine.
+func (e *metaEngine) Close() error { return e.bidx.Close() }
+
+// Save implements internal.Engine.
+func (e *metaEngine) Save(key string, data []byte) error {
+	return e.bidx.SetInternal([]byte(key), data)
+}
+
+// Load implements internal.Engine.
+func (e *metaEngine) Load(key string) ([]byte, error) {
+	return e.bidx.GetInternal([]byte(key))
+}
+
+// Delete implements internal.Engine.
+func (e *metaEngine) Delete(key string) error {
+	return e.bidx.DeleteInternal([]byte(key))
+}
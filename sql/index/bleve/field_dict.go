@@ -0,0 +1,71 @@
+package bleve
+
+import (
+	"io"
+
+	bleveIndex "github.com/blevesearch/bleve/index"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+)
+
+var _ sql.FieldDict = (*index)(nil)
+
+// FieldDict implements sql.FieldDict, exposing bleve's own field-dict API
+// (introduced with its disk format v4) over the field backing expr.
+func (i *index) FieldDict(expr sql.Expression) (sql.FieldDictIter, error) {
+	dict, err := i.bleve.FieldDict(fieldName(expr))
+	if err != nil {
+		return nil, err
+	}
+	return &fieldDictIter{dict: dict}, nil
+}
+
+// FieldDictRange implements sql.FieldDict.
+func (i *index) FieldDictRange(expr sql.Expression, start, end []byte) (sql.FieldDictIter, error) {
+	dict, err := i.bleve.FieldDictRange(fieldName(expr), start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldDictIter{dict: dict}, nil
+}
+
+// FieldDictPrefix implements sql.FieldDict. The plan package's LIKE
+// 'prefix%' and MATCH ... AGAINST pushdown helpers reach it through
+// sql.IndexRegistry.FieldDictPrefix.
+func (i *index) FieldDictPrefix(expr sql.Expression, prefix []byte) (sql.FieldDictIter, error) {
+	dict, err := i.bleve.FieldDictPrefix(fieldName(expr), prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldDictIter{dict: dict}, nil
+}
+
+// fieldName maps an indexed expression to the bleve field its terms are
+// stored under. IndexBatch stores the values of every indexed expression in
+// a single "values" field, so every expression maps to that field and the
+// terms of a multi-column index are not separated by expression.
+func fieldName(expr sql.Expression) string {
+	return "values"
+}
+
+type fieldDictIter struct {
+	dict bleveIndex.FieldDict
+}
+
+// Next implements sql.FieldDictIter.
+func (it *fieldDictIter) Next() ([]byte, uint64, error) {
+	entry, err := it.dict.Next()
+	if err != nil {
+		return nil, 0, err
+	}
+	if entry == nil {
+		return nil, 0, io.EOF
+	}
+
+	return []byte(entry.Term), uint64(entry.Count), nil
+}
+
+// Close implements sql.FieldDictIter.
+func (it *fieldDictIter) Close() error {
+	return it.dict.Close()
+}
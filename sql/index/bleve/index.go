@@ -0,0 +1,116 @@
+package bleve
+
+import (
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+)
+
+// index is the sql.Index implementation backed by a bleve.Index.
+type index struct {
+	db               string
+	table            string
+	id               string
+	expressionHashes []hash.Hash
+	config           Config
+	bleve            bleve.Index
+}
+
+var _ sql.Index = (*index)(nil)
+
+// ID implements sql.Index.
+func (i *index) ID() string { return i.id }
+
+// Database implements sql.Index.
+func (i *index) Database() string { return i.db }
+
+// Table implements sql.Index.
+func (i *index) Table() string { return i.table }
+
+// ExpressionHashes implements sql.Index.
+func (i *index) ExpressionHashes() []hash.Hash { return i.expressionHashes }
+
+// Get implements sql.Index. key is matched as a bleve query string against
+// the indexed fields.
+func (i *index) Get(key interface{}) (sql.IndexLookup, error) {
+	query := bleve.NewQueryStringQuery(fmt.Sprint(key))
+	return &lookup{bleve: i.bleve, query: query}, nil
+}
+
+// Has implements sql.Index.
+func (i *index) Has(key interface{}) (bool, error) {
+	lookup, err := i.Get(key)
+	if err != nil {
+		return false, err
+	}
+
+	iter := lookup.Values()
+	defer iter.Close()
+
+	if _, err := iter.Next(); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+var _ sql.BatchIndexer = (*index)(nil)
+
+// IndexBatch implements sql.BatchIndexer, so sql.ConcurrentSave can drive
+// several of these concurrently from different worker goroutines.
+func (i *index) IndexBatch(kvs []sql.KeyValue) error {
+	batch := i.bleve.NewBatch()
+	for _, kv := range kvs {
+		doc := map[string]interface{}{"values": kv.Values}
+		if err := batch.Index(string(kv.Location), doc); err != nil {
+			return err
+		}
+	}
+	return i.bleve.Batch(batch)
+}
+
+// lookup is the sql.IndexLookup returned by index.Get.
+type lookup struct {
+	bleve bleve.Index
+	query query.Query
+}
+
+// Values implements sql.IndexLookup.
+func (l *lookup) Values() sql.IndexValueIter {
+	req := bleve.NewSearchRequest(l.query)
+	result, err := l.bleve.Search(req)
+	return &valueIter{result: result, err: err}
+}
+
+type valueIter struct {
+	result *bleve.SearchResult
+	pos    int
+	err    error
+}
+
+// Next implements sql.IndexValueIter.
+func (it *valueIter) Next() ([]byte, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+
+	if it.result == nil || it.pos >= len(it.result.Hits) {
+		return nil, io.EOF
+	}
+
+	hit := it.result.Hits[it.pos]
+	it.pos++
+
+	return []byte(hit.ID), nil
+}
+
+// Close implements sql.IndexValueIter.
+func (it *valueIter) Close() error { return nil }
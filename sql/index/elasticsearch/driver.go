@@ -0,0 +1,204 @@
+// Package elasticsearch implements a sql.IndexDriver backed by a remote
+// Elasticsearch cluster.
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash"
+
+	elastic "github.com/olivere/elastic"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/index/internal"
+)
+
+// DriverID is the unique name of this driver, as registered with
+// sql.IndexRegistry.RegisterIndexDriver.
+const DriverID = "elasticsearch"
+
+// schemaVersion is bumped whenever the document mapping this driver writes
+// changes incompatibly.
+const schemaVersion = 1
+
+const schemaVersionField = "gms_schema_version"
+
+// Config are the elasticsearch-specific options parsed out of the config map
+// passed to Driver.Create.
+type Config struct {
+	// URL is the address of the elasticsearch cluster, e.g.
+	// "http://localhost:9200".
+	URL string
+}
+
+func newConfig(config map[string]string) (Config, error) {
+	url := config["url"]
+	if url == "" {
+		return Config{}, fmt.Errorf("elasticsearch driver requires a %q config option", "url")
+	}
+	return Config{URL: url}, nil
+}
+
+// Driver is a sql.IndexDriver that stores indexes as documents in a remote
+// Elasticsearch cluster.
+type Driver struct {
+	// URL is the address of the elasticsearch cluster Load connects to.
+	// NewDriver sets it, and every Create overwrites it with the url from
+	// its config.
+	URL string
+
+	// MaxWorkers bounds the worker pool Save uses to index batches
+	// concurrently. Zero means sql.DefaultMaxIndexBuildWorkers.
+	MaxWorkers int
+	// BatchSize is the number of key/value tuples grouped into a single
+	// bulk request. Zero means sql.DefaultIndexBatchSize.
+	BatchSize int
+}
+
+var _ sql.ConcurrentIndexDriver = (*Driver)(nil)
+
+// NewDriver returns a new elasticsearch Driver talking to the cluster at url.
+func NewDriver(url string) *Driver { return &Driver{URL: url} }
+
+// ID implements sql.IndexDriver.
+func (*Driver) ID() string { return DriverID }
+
+// SchemaVersion implements sql.IndexDriver.
+func (*Driver) SchemaVersion() uint32 { return schemaVersion }
+
+func indexName(db, id string) string {
+	return fmt.Sprintf("gms-%s-%s", db, id)
+}
+
+// Create implements sql.IndexDriver.
+func (d *Driver) Create(db, table, id string, expressionHashes []hash.Hash, config map[string]string) (sql.Index, error) {
+	cfg, err := newConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := elastic.NewClient(elastic.SetURL(cfg.URL))
+	if err != nil {
+		return nil, err
+	}
+	d.URL = cfg.URL
+
+	name := indexName(db, id)
+	ctx := context.Background()
+	if _, err := client.CreateIndex(name).Do(ctx); err != nil {
+		return nil, err
+	}
+
+	return &index{
+		db:               db,
+		table:            table,
+		id:               id,
+		expressionHashes: expressionHashes,
+		config:           cfg,
+		client:           client,
+		name:             name,
+	}, nil
+}
+
+// Load implements sql.IndexDriver.
+func (d *Driver) Load(db, table string) ([]sql.Index, error) {
+	if d.URL == "" {
+		return nil, fmt.Errorf("elasticsearch driver has no cluster URL configured; Create must run before Load")
+	}
+
+	client, err := elastic.NewClient(elastic.SetURL(d.URL))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	names, err := client.IndexNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []sql.Index
+	prefix := fmt.Sprintf("gms-%s-", db)
+	for _, name := range names {
+		if len(name) <= len(prefix) || name[:len(prefix)] != prefix {
+			continue
+		}
+
+		version, err := readSchemaVersion(ctx, client, name)
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && version != schemaVersion {
+			return nil, internal.ErrIncompatibleSchemaVersion.New(name, version, DriverID, schemaVersion)
+		}
+
+		indexes = append(indexes, &index{
+			db:     db,
+			table:  table,
+			id:     name[len(prefix):],
+			client: client,
+			name:   name,
+		})
+	}
+
+	return indexes, nil
+}
+
+func readSchemaVersion(ctx context.Context, client *elastic.Client, name string) (uint32, error) {
+	res, err := client.Get().Index(name).Id(schemaVersionField).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var doc struct {
+		Version uint32 `json:"version"`
+	}
+	if err := json.Unmarshal(*res.Source, &doc); err != nil {
+		return 0, err
+	}
+
+	return doc.Version, nil
+}
+
+// Save implements sql.IndexDriver. It indexes the key stream concurrently
+// across a worker pool, see sql.ConcurrentSave.
+func (d *Driver) Save(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter) error {
+	return d.SaveWithProgress(ctx, idx, iter, nil)
+}
+
+// SaveWithProgress implements sql.ConcurrentIndexDriver.
+func (d *Driver) SaveWithProgress(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter, progress sql.ProgressReporter) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("elasticsearch driver can't save index of type %T", idx)
+	}
+	return sql.ConcurrentSave(ctx, i, iter, d.MaxWorkers, d.BatchSize, progress)
+}
+
+// SaveResumable implements sql.ConcurrentIndexDriver. Skipping already-
+// indexed tuples on resume is handled by IndexRegistry.SaveResumable before
+// iter reaches here; checkpoint is called once a batch - and every batch
+// read before it - has actually finished indexing.
+func (d *Driver) SaveResumable(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter, progress sql.ProgressReporter, checkpoint func(location []byte) error) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("elasticsearch driver can't save index of type %T", idx)
+	}
+	return sql.ConcurrentSaveResumable(ctx, i, iter, d.MaxWorkers, d.BatchSize, progress, checkpoint)
+}
+
+// Delete implements sql.IndexDriver.
+func (d *Driver) Delete(idx sql.Index) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("elasticsearch driver can't delete index of type %T", idx)
+	}
+
+	ctx := context.Background()
+	_, err := i.client.DeleteIndex(i.name).Do(ctx)
+	return err
+}
@@ -0,0 +1,120 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+
+	elastic "github.com/olivere/elastic"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+)
+
+// index is the sql.Index implementation backed by an elasticsearch index.
+// Each indexed row is stored as a document whose _id is its repo location
+// and whose "values" field holds the indexed column values.
+type index struct {
+	db               string
+	table            string
+	id               string
+	expressionHashes []hash.Hash
+	config           Config
+	client           *elastic.Client
+	name             string
+}
+
+var _ sql.Index = (*index)(nil)
+
+// ID implements sql.Index.
+func (i *index) ID() string { return i.id }
+
+// Database implements sql.Index.
+func (i *index) Database() string { return i.db }
+
+// Table implements sql.Index.
+func (i *index) Table() string { return i.table }
+
+// ExpressionHashes implements sql.Index.
+func (i *index) ExpressionHashes() []hash.Hash { return i.expressionHashes }
+
+// Get implements sql.Index.
+func (i *index) Get(key interface{}) (sql.IndexLookup, error) {
+	query := elastic.NewMatchQuery("values", fmt.Sprint(key))
+	return &lookup{client: i.client, name: i.name, query: query}, nil
+}
+
+// Has implements sql.Index.
+func (i *index) Has(key interface{}) (bool, error) {
+	lookup, err := i.Get(key)
+	if err != nil {
+		return false, err
+	}
+
+	iter := lookup.Values()
+	defer iter.Close()
+
+	if _, err := iter.Next(); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+var _ sql.BatchIndexer = (*index)(nil)
+
+// IndexBatch implements sql.BatchIndexer, so sql.ConcurrentSave can drive
+// several of these concurrently from different worker goroutines.
+func (i *index) IndexBatch(kvs []sql.KeyValue) error {
+	bulk := i.client.Bulk().Index(i.name)
+
+	for _, kv := range kvs {
+		doc := map[string]interface{}{"values": kv.Values}
+		bulk = bulk.Add(elastic.NewBulkIndexRequest().Id(string(kv.Location)).Doc(doc))
+	}
+
+	_, err := bulk.Do(context.Background())
+	return err
+}
+
+// lookup is the sql.IndexLookup returned by index.Get.
+type lookup struct {
+	client *elastic.Client
+	name   string
+	query  elastic.Query
+}
+
+// Values implements sql.IndexLookup.
+func (l *lookup) Values() sql.IndexValueIter {
+	ctx := context.Background()
+	res, err := l.client.Search().Index(l.name).Query(l.query).Do(ctx)
+	return &valueIter{result: res, err: err}
+}
+
+type valueIter struct {
+	result *elastic.SearchResult
+	pos    int
+	err    error
+}
+
+// Next implements sql.IndexValueIter.
+func (it *valueIter) Next() ([]byte, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+
+	if it.result == nil || it.pos >= len(it.result.Hits.Hits) {
+		return nil, io.EOF
+	}
+
+	hit := it.result.Hits.Hits[it.pos]
+	it.pos++
+
+	return []byte(hit.Id), nil
+}
+
+// Close implements sql.IndexValueIter.
+func (it *valueIter) Close() error { return nil }
@@ -0,0 +1,34 @@
+// Package internal defines the low-level storage contract shared by the
+// concrete index engines (bleve, pilosa, elasticsearch). It is not meant to
+// be used outside of the sql/index engine packages.
+package internal
+
+import "gopkg.in/src-d/go-errors.v1"
+
+// Engine is the minimal set of operations a concrete index storage engine
+// provides. Of the bundled engines only bleve implements it, for the schema
+// metadata it keeps in its index; the pilosa and elasticsearch drivers do
+// not implement it and share only ErrIncompatibleSchemaVersion from this
+// package.
+type Engine interface {
+	// Init opens or creates the engine's storage at path.
+	Init(path string) error
+	// Ping checks that the engine's storage is reachable.
+	Ping() error
+	// Close releases any resources held by the engine.
+	Close() error
+	// Save persists data under key.
+	Save(key string, data []byte) error
+	// Load retrieves the data previously saved under key.
+	Load(key string) ([]byte, error)
+	// Delete removes the data stored under key.
+	Delete(key string) error
+}
+
+// ErrIncompatibleSchemaVersion is returned by Load when the on-disk or
+// remote representation of an index was written by a schema version the
+// current driver can no longer read. Callers should treat this as a signal
+// to rebuild the index rather than a fatal error.
+var ErrIncompatibleSchemaVersion = errors.NewKind(
+	"index %q was written with schema version %d, but the %q driver requires version %d; the index needs to be rebuilt",
+)
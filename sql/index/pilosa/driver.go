@@ -0,0 +1,221 @@
+// Package pilosa implements a sql.IndexDriver backed by pilosa bitmap
+// indexes, well suited to low-cardinality columns used in equality joins.
+package pilosa
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"strconv"
+
+	pilosa "github.com/pilosa/go-pilosa"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+	"gopkg.in/src-d/go-mysql-server.v0/sql/index/internal"
+)
+
+// DriverID is the unique name of this driver, as registered with
+// sql.IndexRegistry.RegisterIndexDriver.
+const DriverID = "pilosa"
+
+// schemaVersion is bumped whenever the field layout this driver writes
+// changes incompatibly.
+const schemaVersion = 1
+
+// valuesFieldName is the pilosa field rows are set on: row ID is the indexed
+// value (via rowID), column ID is the repo location (via index.nextCol).
+const valuesFieldName = "values"
+
+// metaFieldName is the pilosa field the driver stamps its schema version
+// onto, as row attributes on metaRowID.
+const metaFieldName = "gms_meta"
+
+const metaRowID = uint64(0)
+
+// Config are the pilosa-specific options parsed out of the config map
+// passed to Driver.Create.
+type Config struct {
+	// Shards is the "shards" config option, kept on the index. Defaults to
+	// 1. Create does not pass it to pilosa, so it does not change how the
+	// pilosa index is laid out.
+	Shards uint64
+}
+
+func newConfig(config map[string]string) (Config, error) {
+	cfg := Config{Shards: 1}
+	if v, ok := config["shards"]; ok {
+		shards, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid pilosa shards %q: %s", v, err)
+		}
+		cfg.Shards = shards
+	}
+	return cfg, nil
+}
+
+// Driver is a sql.IndexDriver that stores indexes as pilosa bitmap indexes.
+type Driver struct {
+	client *pilosa.Client
+
+	// MaxWorkers bounds the worker pool Save uses to index batches
+	// concurrently. Zero means sql.DefaultMaxIndexBuildWorkers.
+	MaxWorkers int
+	// BatchSize is the number of key/value tuples grouped into a single
+	// pilosa batch. Zero means sql.DefaultIndexBatchSize.
+	BatchSize int
+}
+
+var _ sql.ConcurrentIndexDriver = (*Driver)(nil)
+
+// NewDriver returns a new pilosa Driver talking to the server at addr.
+func NewDriver(addr string) (*Driver, error) {
+	client, err := pilosa.NewClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Driver{client: client}, nil
+}
+
+// ID implements sql.IndexDriver.
+func (*Driver) ID() string { return DriverID }
+
+// SchemaVersion implements sql.IndexDriver.
+func (*Driver) SchemaVersion() uint32 { return schemaVersion }
+
+func indexName(db, id string) string {
+	return fmt.Sprintf("%s-%s", db, id)
+}
+
+// Create implements sql.IndexDriver.
+func (d *Driver) Create(db, table, id string, expressionHashes []hash.Hash, config map[string]string) (sql.Index, error) {
+	cfg, err := newConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := pilosa.NewSchema()
+	idx := schema.Index(indexName(db, id))
+	field := idx.Field(valuesFieldName)
+	idx.Field(metaFieldName)
+
+	if err := d.client.SyncSchema(schema); err != nil {
+		return nil, err
+	}
+
+	if err := d.writeSchemaVersion(idx); err != nil {
+		return nil, err
+	}
+
+	return &index{
+		db:               db,
+		table:            table,
+		id:               id,
+		expressionHashes: expressionHashes,
+		config:           cfg,
+		client:           d.client,
+		pilosaIndex:      idx,
+		field:            field,
+	}, nil
+}
+
+// Load implements sql.IndexDriver.
+func (d *Driver) Load(db, table string) ([]sql.Index, error) {
+	schema, err := d.client.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []sql.Index
+	for _, idx := range schema.Indexes() {
+		if !idx.HasField(valuesFieldName) {
+			continue
+		}
+		field := idx.Field(valuesFieldName)
+
+		version, err := d.readSchemaVersion(idx)
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && version != schemaVersion {
+			return nil, internal.ErrIncompatibleSchemaVersion.New(idx.Name(), version, DriverID, schemaVersion)
+		}
+
+		indexes = append(indexes, &index{
+			db:          db,
+			table:       table,
+			id:          idx.Name(),
+			client:      d.client,
+			pilosaIndex: idx,
+			field:       field,
+		})
+	}
+
+	return indexes, nil
+}
+
+// writeSchemaVersion stamps idx's meta field with the driver's current
+// schema version, as row attributes on metaRowID.
+func (d *Driver) writeSchemaVersion(idx *pilosa.Index) error {
+	field := idx.Field(metaFieldName)
+	_, err := d.client.Query(field.SetRowAttrs(metaRowID, map[string]interface{}{
+		"schema_version": int64(schemaVersion),
+	}))
+	return err
+}
+
+// readSchemaVersion fetches the schema version tag stored in idx's meta
+// field, returning 0 if none was ever written - either because idx predates
+// the introduction of the meta field, or because no version was ever set.
+func (d *Driver) readSchemaVersion(idx *pilosa.Index) (uint32, error) {
+	if !idx.HasField(metaFieldName) {
+		return 0, nil
+	}
+
+	field := idx.Field(metaFieldName)
+	resp, err := d.client.Query(field.Row(metaRowID))
+	if err != nil {
+		return 0, err
+	}
+
+	version, ok := resp.Result().Row().Attributes["schema_version"].(int64)
+	if !ok {
+		return 0, nil
+	}
+	return uint32(version), nil
+}
+
+// Save implements sql.IndexDriver. It indexes the key stream concurrently
+// across a worker pool, see sql.ConcurrentSave.
+func (d *Driver) Save(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter) error {
+	return d.SaveWithProgress(ctx, idx, iter, nil)
+}
+
+// SaveWithProgress implements sql.ConcurrentIndexDriver.
+func (d *Driver) SaveWithProgress(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter, progress sql.ProgressReporter) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("pilosa driver can't save index of type %T", idx)
+	}
+	return sql.ConcurrentSave(ctx, i, iter, d.MaxWorkers, d.BatchSize, progress)
+}
+
+// SaveResumable implements sql.ConcurrentIndexDriver. Skipping already-
+// indexed tuples on resume is handled by IndexRegistry.SaveResumable before
+// iter reaches here; checkpoint is called once a batch - and every batch
+// read before it - has actually finished indexing.
+func (d *Driver) SaveResumable(ctx context.Context, idx sql.Index, iter sql.IndexKeyValueIter, progress sql.ProgressReporter, checkpoint func(location []byte) error) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("pilosa driver can't save index of type %T", idx)
+	}
+	return sql.ConcurrentSaveResumable(ctx, i, iter, d.MaxWorkers, d.BatchSize, progress, checkpoint)
+}
+
+// Delete implements sql.IndexDriver.
+func (d *Driver) Delete(idx sql.Index) error {
+	i, ok := idx.(*index)
+	if !ok {
+		return fmt.Errorf("pilosa driver can't delete index of type %T", idx)
+	}
+	return d.client.DeleteIndex(i.pilosaIndex)
+}
@@ -0,0 +1,166 @@
+package pilosa
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	pilosa "github.com/pilosa/go-pilosa"
+
+	"gopkg.in/src-d/go-mysql-server.v0/sql"
+)
+
+// index is the sql.Index implementation backed by a pilosa bitmap field. Row
+// repo locations are mapped to pilosa column IDs and values are mapped to
+// pilosa row IDs, via rowID.
+type index struct {
+	db               string
+	table            string
+	id               string
+	expressionHashes []hash.Hash
+	config           Config
+	client           *pilosa.Client
+	pilosaIndex      *pilosa.Index
+	field            *pilosa.Field
+
+	nextCol uint64
+
+	locationsMut sync.RWMutex
+	locations    map[uint64][]byte
+}
+
+var _ sql.Index = (*index)(nil)
+
+// ID implements sql.Index.
+func (i *index) ID() string { return i.id }
+
+// Database implements sql.Index.
+func (i *index) Database() string { return i.db }
+
+// Table implements sql.Index.
+func (i *index) Table() string { return i.table }
+
+// ExpressionHashes implements sql.Index.
+func (i *index) ExpressionHashes() []hash.Hash { return i.expressionHashes }
+
+// Get implements sql.Index.
+func (i *index) Get(key interface{}) (sql.IndexLookup, error) {
+	row, err := rowID(key)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := i.client.Query(i.field.Row(row))
+	if err != nil {
+		return nil, err
+	}
+
+	i.locationsMut.RLock()
+	defer i.locationsMut.RUnlock()
+
+	locations := make([][]byte, 0, len(resp.Result().Row().Columns))
+	for _, col := range resp.Result().Row().Columns {
+		if loc, ok := i.locations[col]; ok {
+			locations = append(locations, loc)
+		}
+	}
+
+	return &lookup{locations: locations}, nil
+}
+
+// Has implements sql.Index.
+func (i *index) Has(key interface{}) (bool, error) {
+	lookup, err := i.Get(key)
+	if err != nil {
+		return false, err
+	}
+
+	iter := lookup.Values()
+	defer iter.Close()
+
+	if _, err := iter.Next(); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+var _ sql.BatchIndexer = (*index)(nil)
+
+// IndexBatch implements sql.BatchIndexer, so sql.ConcurrentSave can drive
+// several of these concurrently from different worker goroutines. Each
+// key/value tuple is assigned the next pilosa column ID off nextCol,
+// atomically, so column IDs stay unique across concurrent batches.
+func (i *index) IndexBatch(batch []sql.KeyValue) error {
+	for _, kv := range batch {
+		col := atomic.AddUint64(&i.nextCol, 1) - 1
+
+		for _, v := range kv.Values {
+			row, err := rowID(v)
+			if err != nil {
+				return err
+			}
+
+			if _, err := i.client.Query(i.field.Set(row, col)); err != nil {
+				return err
+			}
+		}
+
+		i.locationsMut.Lock()
+		if i.locations == nil {
+			i.locations = make(map[uint64][]byte)
+		}
+		i.locations[col] = kv.Location
+		i.locationsMut.Unlock()
+	}
+
+	return nil
+}
+
+func rowID(key interface{}) (uint64, error) {
+	switch v := key.(type) {
+	case uint64:
+		return v, nil
+	case int:
+		return uint64(v), nil
+	case int64:
+		return uint64(v), nil
+	default:
+		return 0, fmt.Errorf("pilosa index: unsupported key type %T", key)
+	}
+}
+
+// lookup is the sql.IndexLookup returned by index.Get.
+type lookup struct {
+	locations [][]byte
+}
+
+// Values implements sql.IndexLookup.
+func (l *lookup) Values() sql.IndexValueIter {
+	return &valueIter{locations: l.locations}
+}
+
+type valueIter struct {
+	locations [][]byte
+	pos       int
+}
+
+// Next implements sql.IndexValueIter.
+func (it *valueIter) Next() ([]byte, error) {
+	if it.pos >= len(it.locations) {
+		return nil, io.EOF
+	}
+
+	loc := it.locations[it.pos]
+	it.pos++
+
+	return loc, nil
+}
+
+// Close implements sql.IndexValueIter.
+func (it *valueIter) Close() error { return nil }
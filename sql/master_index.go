@@ -0,0 +1,221 @@
+package sql
+
+import (
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// MasterIndex composes an ordered list of Index instances that all index the
+// same (db, expressions) tuple, so a rebuild can populate a new component
+// while the old one stays queryable. Get and Has fan out to every component
+// and merge the results, and pendingKeys tracks keys a concurrent writer has
+// already emitted to the new component but not yet flushed, so Has can
+// answer without a full scan while a CREATE INDEX rebuild races an UPDATE.
+type MasterIndex struct {
+	mut        sync.RWMutex
+	components []Index
+
+	pendingMut  sync.Mutex
+	pendingKeys map[string]struct{}
+}
+
+var _ Index = (*MasterIndex)(nil)
+
+// NewMasterIndex returns a MasterIndex composed of the given components, in
+// order. The first component is treated as authoritative for ID, Database,
+// Table and ExpressionHashes, since every component is expected to index the
+// same table and expressions.
+func NewMasterIndex(components ...Index) *MasterIndex {
+	return &MasterIndex{
+		components:  components,
+		pendingKeys: make(map[string]struct{}),
+	}
+}
+
+// Components returns a snapshot of the current component list.
+func (m *MasterIndex) Components() []Index {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	components := make([]Index, len(m.components))
+	copy(components, m.components)
+	return components
+}
+
+// AddComponent appends a new component to the index, typically the target of
+// an in-progress rebuild. It becomes queryable immediately, alongside the
+// existing components.
+func (m *MasterIndex) AddComponent(idx Index) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.components = append(m.components, idx)
+}
+
+// MergeFinalIndexes atomically replaces the component list with the single
+// compacted index, once a rebuild has finished and the old components are no
+// longer needed. It returns the previous components, so the caller can
+// release and delete them.
+func (m *MasterIndex) MergeFinalIndexes(compacted Index) []Index {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	old := m.components
+	m.components = []Index{compacted}
+
+	m.pendingMut.Lock()
+	m.pendingKeys = make(map[string]struct{})
+	m.pendingMut.Unlock()
+
+	return old
+}
+
+// MarkPending records that key has already been written to the newest
+// component but may not be visible there yet (e.g. the write hasn't been
+// flushed), so Has can answer true for it without scanning the component.
+func (m *MasterIndex) MarkPending(key interface{}) {
+	m.pendingMut.Lock()
+	defer m.pendingMut.Unlock()
+	m.pendingKeys[fmt.Sprint(key)] = struct{}{}
+}
+
+// UnmarkPending clears a key previously marked with MarkPending, once it's
+// safe to rely on the component's own Has again (the write was flushed).
+func (m *MasterIndex) UnmarkPending(key interface{}) {
+	m.pendingMut.Lock()
+	defer m.pendingMut.Unlock()
+	delete(m.pendingKeys, fmt.Sprint(key))
+}
+
+func (m *MasterIndex) isPending(key interface{}) bool {
+	m.pendingMut.Lock()
+	defer m.pendingMut.Unlock()
+	_, ok := m.pendingKeys[fmt.Sprint(key)]
+	return ok
+}
+
+// ID implements Index. It delegates to the first component.
+func (m *MasterIndex) ID() string {
+	components := m.Components()
+	if len(components) == 0 {
+		return ""
+	}
+	return components[0].ID()
+}
+
+// Database implements Index. It delegates to the first component.
+func (m *MasterIndex) Database() string {
+	components := m.Components()
+	if len(components) == 0 {
+		return ""
+	}
+	return components[0].Database()
+}
+
+// Table implements Index. It delegates to the first component.
+func (m *MasterIndex) Table() string {
+	components := m.Components()
+	if len(components) == 0 {
+		return ""
+	}
+	return components[0].Table()
+}
+
+// ExpressionHashes implements Index. It delegates to the first component.
+func (m *MasterIndex) ExpressionHashes() []hash.Hash {
+	components := m.Components()
+	if len(components) == 0 {
+		return nil
+	}
+	return components[0].ExpressionHashes()
+}
+
+// Get implements Index. It fans the lookup out to every component and
+// merges the results with newAliasLookup, the same dedup-by-repo-location
+// merge IndexAlias uses to span several sharded indexes, since none of the
+// bundled drivers' IndexLookups implement SetOperations.
+func (m *MasterIndex) Get(key interface{}) (IndexLookup, error) {
+	components := m.Components()
+	if len(components) == 0 {
+		return nil, ErrIndexNotFound.New("<no components>")
+	}
+
+	lookups := make([]IndexLookup, len(components))
+	for i, c := range components {
+		lookup, err := c.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		lookups[i] = lookup
+	}
+
+	return newAliasLookup(lookups...), nil
+}
+
+// Has implements Index. A key marked as pending is reported present
+// immediately, without scanning any component.
+func (m *MasterIndex) Has(key interface{}) (bool, error) {
+	if m.isPending(key) {
+		return true, nil
+	}
+
+	for _, c := range m.Components() {
+		ok, err := c.Has(key)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// BeginRebuild starts a rebuild of the index registered for (db, expr): it
+// wraps the existing index in a MasterIndex, if it isn't one already, adds
+// newIdx as an additional component, and publishes the MasterIndex in its
+// place. The old index stays queryable through the returned MasterIndex
+// while newIdx is populated (e.g. with ConcurrentSave), instead of the
+// all-or-nothing IndexNotReady/IndexReady flag a bare AddIndex would give.
+func (r *IndexRegistry) BeginRebuild(db string, expr []Expression, newIdx Index) (*MasterIndex, error) {
+	var expressionHashes []hash.Hash
+	for _, e := range expr {
+		expressionHashes = append(expressionHashes, NewExpressionHash(e))
+	}
+
+	txn := r.Txn()
+
+	var key indexKey
+	var found Index
+	for k, idx := range txn.work.indexes {
+		if idx.Database() == db && exprListsEqual(idx.ExpressionHashes(), expressionHashes) {
+			key, found = k, idx
+			break
+		}
+	}
+
+	if found == nil {
+		txn.Abort()
+		return nil, ErrIndexNotFound.New("<expression>")
+	}
+
+	mi, ok := found.(*MasterIndex)
+	if !ok {
+		mi = NewMasterIndex(found)
+		txn.work.indexes[key] = mi
+	}
+	mi.AddComponent(newIdx)
+
+	txn.Commit()
+
+	return mi, nil
+}
+
+// FinishRebuild compacts mi down to the single, fully-populated index,
+// dropping the components that preceded it. Callers should only call this
+// once the old components' refcounts have drained, e.g. after waiting on the
+// channel DeleteIndex would have returned for them.
+func (r *IndexRegistry) FinishRebuild(mi *MasterIndex, compacted Index) []Index {
+	return mi.MergeFinalIndexes(compacted)
+}
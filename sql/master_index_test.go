@@ -0,0 +1,92 @@
+package sql
+
+import (
+	"hash"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeComponentIndex struct {
+	id, db, table string
+	values        [][]byte
+}
+
+func (i *fakeComponentIndex) ID() string                    { return i.id }
+func (i *fakeComponentIndex) Database() string              { return i.db }
+func (i *fakeComponentIndex) Table() string                 { return i.table }
+func (i *fakeComponentIndex) ExpressionHashes() []hash.Hash { return nil }
+func (i *fakeComponentIndex) Get(key interface{}) (IndexLookup, error) {
+	return &sliceLookup{values: i.values}, nil
+}
+func (i *fakeComponentIndex) Has(key interface{}) (bool, error) {
+	return len(i.values) > 0, nil
+}
+
+func TestMasterIndexGetMergesAllComponents(t *testing.T) {
+	require := require.New(t)
+
+	a := &fakeComponentIndex{id: "idx", db: "db", table: "t", values: [][]byte{[]byte("a"), []byte("b")}}
+	b := &fakeComponentIndex{id: "idx", db: "db", table: "t", values: [][]byte{[]byte("b"), []byte("c")}}
+
+	mi := NewMasterIndex(a, b)
+
+	lookup, err := mi.Get("key")
+	require.NoError(err)
+
+	got := drainValues(t, lookup.Values())
+	require.Equal([][]byte{[]byte("a"), []byte("b"), []byte("c")}, got)
+}
+
+func TestMasterIndexAddComponentIsQueryable(t *testing.T) {
+	require := require.New(t)
+
+	a := &fakeComponentIndex{id: "idx", db: "db", table: "t", values: [][]byte{[]byte("a")}}
+	mi := NewMasterIndex(a)
+
+	b := &fakeComponentIndex{id: "idx", db: "db", table: "t", values: [][]byte{[]byte("b")}}
+	mi.AddComponent(b)
+
+	lookup, err := mi.Get("key")
+	require.NoError(err)
+
+	got := drainValues(t, lookup.Values())
+	require.Equal([][]byte{[]byte("a"), []byte("b")}, got)
+}
+
+func TestMasterIndexPendingKeys(t *testing.T) {
+	require := require.New(t)
+
+	mi := NewMasterIndex(&fakeComponentIndex{id: "idx", db: "db", table: "t"})
+
+	ok, err := mi.Has("k")
+	require.NoError(err)
+	require.False(ok)
+
+	mi.MarkPending("k")
+	ok, err = mi.Has("k")
+	require.NoError(err)
+	require.True(ok)
+
+	mi.UnmarkPending("k")
+	ok, err = mi.Has("k")
+	require.NoError(err)
+	require.False(ok)
+}
+
+func TestMasterIndexMergeFinalIndexes(t *testing.T) {
+	require := require.New(t)
+
+	a := &fakeComponentIndex{id: "idx", db: "db", table: "t"}
+	b := &fakeComponentIndex{id: "idx", db: "db", table: "t"}
+	mi := NewMasterIndex(a, b)
+	mi.MarkPending("k")
+
+	old := mi.MergeFinalIndexes(&fakeComponentIndex{id: "idx", db: "db", table: "t"})
+	require.Equal([]Index{a, b}, old)
+	require.Equal(1, len(mi.Components()))
+
+	ok, err := mi.Has("k")
+	require.NoError(err)
+	require.False(ok)
+}
@@ -0,0 +1,49 @@
+package plan
+
+import "gopkg.in/src-d/go-mysql-server.v0/sql"
+
+// PrefixPattern recognizes a SQL LIKE pattern of the form "foo%" — a literal
+// prefix followed by a single trailing wildcard and nothing else. It returns
+// the literal prefix and true when pattern has that shape, so a Filter can
+// push the match down to an index's FieldDictPrefix scan instead of reading
+// every row. Any other wildcard usage (a leading "%", an internal "%" or
+// "_", or no wildcard at all) returns ok == false.
+func PrefixPattern(pattern string) (prefix string, ok bool) {
+	if len(pattern) == 0 || pattern[len(pattern)-1] != '%' {
+		return "", false
+	}
+
+	prefix = pattern[:len(pattern)-1]
+	for _, r := range prefix {
+		if r == '%' || r == '_' {
+			return "", false
+		}
+	}
+
+	return prefix, true
+}
+
+// PushdownFieldDictPrefix answers a `col LIKE 'prefix%'` filter against idx's
+// term dictionary instead of scanning every row, for indexes whose driver
+// implements sql.FieldDict. The returned iterator yields the matching terms
+// and their doc counts; callers should Close it once done.
+//
+// Scaffolding: this package has no Filter node or optimizer rule yet, so
+// nothing in the planner calls this. It exists so that pushdown can be wired
+// in as soon as that infrastructure lands, without another round-trip through
+// the index registry's FieldDict API.
+func PushdownFieldDictPrefix(registry *sql.IndexRegistry, idx sql.Index, expr sql.Expression, prefix string) (sql.FieldDictIter, error) {
+	return registry.FieldDictPrefix(idx, expr, []byte(prefix))
+}
+
+// PushdownMatchAgainst answers a `MATCH (col) AGAINST ('against')` filter
+// with a prefix scan of idx's term dictionary for expr, for indexes whose
+// driver implements sql.FieldDict. The returned iterator yields every indexed
+// term that starts with against, and its doc count; callers should Close it
+// once done.
+//
+// Scaffolding: same caveat as PushdownFieldDictPrefix above - no Filter node
+// or optimizer rule in this package invokes it yet.
+func PushdownMatchAgainst(registry *sql.IndexRegistry, idx sql.Index, expr sql.Expression, against string) (sql.FieldDictIter, error) {
+	return registry.FieldDictPrefix(idx, expr, []byte(against))
+}
@@ -0,0 +1,30 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixPattern(t *testing.T) {
+	require := require.New(t)
+
+	prefix, ok := PrefixPattern("foo%")
+	require.True(ok)
+	require.Equal("foo", prefix)
+
+	_, ok = PrefixPattern("%foo")
+	require.False(ok)
+
+	_, ok = PrefixPattern("fo%o")
+	require.False(ok)
+
+	_, ok = PrefixPattern("fo_o%")
+	require.False(ok)
+
+	_, ok = PrefixPattern("foo")
+	require.False(ok)
+
+	_, ok = PrefixPattern("")
+	require.False(ok)
+}
@@ -0,0 +1,220 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeOp describes the kind of change a ChangeSet entry records.
+type ChangeOp byte
+
+const (
+	// IndexAdded means the index was added to the registry.
+	IndexAdded ChangeOp = iota
+	// IndexDeleted means the index was removed from the registry.
+	IndexDeleted
+)
+
+// Change is a single entry of a ChangeSet, describing how one index changed
+// as part of a committed RegistryTxn.
+type Change struct {
+	// Before is the index as it was before the change, or nil if it didn't
+	// exist.
+	Before Index
+	// After is the index as it is after the change, or nil if it was
+	// deleted.
+	After Index
+	// Op is the kind of change that happened.
+	Op ChangeOp
+}
+
+// ChangeSet is the list of changes produced by a committed RegistryTxn. It
+// lets a planner invalidate cached plans, or an external observer react to
+// index lifecycle events, without polling the registry.
+type ChangeSet []Change
+
+// registrySnapshot is an immutable, point-in-time view of the index
+// definitions known to an IndexRegistry. Reads outside of a transaction load
+// the currently published snapshot instead of taking a lock, so they never
+// block on, or are blocked by, a write transaction.
+type registrySnapshot struct {
+	indexes  map[indexKey]Index
+	statuses map[indexKey]IndexStatus
+}
+
+func newRegistrySnapshot() *registrySnapshot {
+	return &registrySnapshot{
+		indexes:  make(map[indexKey]Index),
+		statuses: make(map[indexKey]IndexStatus),
+	}
+}
+
+// clone returns a copy-on-write snapshot that a RegistryTxn can mutate freely
+// without affecting the published one.
+func (s *registrySnapshot) clone() *registrySnapshot {
+	clone := &registrySnapshot{
+		indexes:  make(map[indexKey]Index, len(s.indexes)),
+		statuses: make(map[indexKey]IndexStatus, len(s.statuses)),
+	}
+	for k, v := range s.indexes {
+		clone.indexes[k] = v
+	}
+	for k, v := range s.statuses {
+		clone.statuses[k] = v
+	}
+	return clone
+}
+
+// RegistryTxn is a write transaction against an IndexRegistry. It shadows the
+// registry's published index and status maps with a copy-on-write snapshot;
+// mutations are only visible to other readers once Commit is called.
+//
+// A RegistryTxn is not safe for concurrent use. Only one write transaction
+// may be open against a registry at a time; Txn blocks until any previous
+// transaction is committed or aborted.
+type RegistryTxn struct {
+	registry *IndexRegistry
+	work     *registrySnapshot
+
+	tracking bool
+	changes  ChangeSet
+
+	closed bool
+}
+
+// Txn starts a new write transaction against the registry. The caller must
+// call Commit or Abort to release it.
+func (r *IndexRegistry) Txn() *RegistryTxn {
+	r.mut.Lock()
+	return &RegistryTxn{
+		registry: r,
+		work:     r.snapshot().clone(),
+	}
+}
+
+// TrackChanges makes the transaction record a ChangeSet of every index
+// addition and deletion performed through it, retrievable from Commit.
+func (t *RegistryTxn) TrackChanges() {
+	t.tracking = true
+}
+
+func (t *RegistryTxn) record(before, after Index, op ChangeOp) {
+	if t.tracking {
+		t.changes = append(t.changes, Change{Before: before, After: after, Op: op})
+	}
+}
+
+// AddIndex adds idx to the transaction's working snapshot. The index is not
+// visible to readers until Commit is called.
+func (t *RegistryTxn) AddIndex(idx Index) error {
+	if err := t.validateIndexToAdd(idx); err != nil {
+		return err
+	}
+
+	key := indexKey{idx.Database(), idx.ID()}
+	t.work.indexes[key] = idx
+	t.work.statuses[key] = IndexNotReady
+	t.record(nil, idx, IndexAdded)
+
+	return nil
+}
+
+// MarkReady flips the status of the given index to IndexReady in the
+// transaction's working snapshot.
+func (t *RegistryTxn) MarkReady(idx Index) {
+	t.work.statuses[indexKey{idx.Database(), idx.ID()}] = IndexReady
+}
+
+// DeleteIndex removes the index with the given id from the transaction's
+// working snapshot.
+func (t *RegistryTxn) DeleteIndex(db, id string) (Index, error) {
+	var key indexKey
+	var idx Index
+	for k, i := range t.work.indexes {
+		if k.db == db && strings.ToLower(id) == i.ID() {
+			key, idx = k, i
+			break
+		}
+	}
+
+	if idx == nil {
+		return nil, ErrIndexNotFound.New(id)
+	}
+
+	delete(t.work.indexes, key)
+	delete(t.work.statuses, key)
+	t.record(idx, nil, IndexDeleted)
+
+	return idx, nil
+}
+
+// addAlias registers target under alias in the transaction's working
+// snapshot, creating the alias if needed or adding target as one more shard
+// of an existing one.
+func (t *RegistryTxn) addAlias(db, alias string, target Index) error {
+	key := indexKey{db, strings.ToLower(alias)}
+
+	if idx, ok := t.work.indexes[key]; ok {
+		ia, ok := idx.(*IndexAlias)
+		if !ok {
+			return ErrIndexIDAlreadyRegistered.New(alias)
+		}
+		ia.addTarget(target)
+		return nil
+	}
+
+	ia := NewIndexAlias(db, alias)
+	ia.addTarget(target)
+	t.work.indexes[key] = ia
+	t.work.statuses[key] = IndexReady
+	t.record(nil, ia, IndexAdded)
+
+	return nil
+}
+
+func (t *RegistryTxn) validateIndexToAdd(idx Index) error {
+	for _, i := range t.work.indexes {
+		if i.Database() != idx.Database() {
+			continue
+		}
+
+		if i.ID() == idx.ID() {
+			return ErrIndexIDAlreadyRegistered.New(idx.ID())
+		}
+
+		if exprListsEqual(i.ExpressionHashes(), idx.ExpressionHashes()) {
+			var exprs = make([]string, len(idx.ExpressionHashes()))
+			for i, e := range idx.ExpressionHashes() {
+				exprs[i] = fmt.Sprintf("%x", e.Sum(nil))
+			}
+			return ErrIndexExpressionAlreadyRegistered.New(strings.Join(exprs, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Commit publishes the transaction's working snapshot, making its changes
+// visible to readers, and returns the ChangeSet recorded if TrackChanges was
+// called (nil otherwise).
+func (t *RegistryTxn) Commit() ChangeSet {
+	if t.closed {
+		return nil
+	}
+
+	t.registry.publish(t.work)
+	t.closed = true
+	t.registry.mut.Unlock()
+
+	return t.changes
+}
+
+// Abort discards the transaction's working snapshot without publishing it.
+func (t *RegistryTxn) Abort() {
+	if t.closed {
+		return
+	}
+
+	t.closed = true
+	t.registry.mut.Unlock()
+}
@@ -0,0 +1,75 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryTxnCommitPublishesChanges(t *testing.T) {
+	require := require.New(t)
+
+	r := NewIndexRegistry()
+	idx := &fakeComponentIndex{id: "idx", db: "db", table: "t"}
+
+	txn := r.Txn()
+	txn.TrackChanges()
+	require.NoError(txn.AddIndex(idx))
+
+	// Not visible to readers until Commit.
+	require.Nil(r.Index("db", "idx"))
+
+	changes := txn.Commit()
+	require.Equal(ChangeSet{{Before: nil, After: idx, Op: IndexAdded}}, changes)
+	require.Equal(idx, r.Index("db", "idx"))
+}
+
+func TestRegistryTxnAbortDiscardsChanges(t *testing.T) {
+	require := require.New(t)
+
+	r := NewIndexRegistry()
+	idx := &fakeComponentIndex{id: "idx", db: "db", table: "t"}
+
+	txn := r.Txn()
+	require.NoError(txn.AddIndex(idx))
+	txn.Abort()
+
+	require.Nil(r.Index("db", "idx"))
+}
+
+func TestRegistryTxnAddIndexDuplicateID(t *testing.T) {
+	require := require.New(t)
+
+	r := NewIndexRegistry()
+	idx := &fakeComponentIndex{id: "idx", db: "db", table: "t"}
+
+	txn := r.Txn()
+	require.NoError(txn.AddIndex(idx))
+	txn.Commit()
+
+	txn = r.Txn()
+	err := txn.AddIndex(&fakeComponentIndex{id: "idx", db: "db", table: "t2"})
+	txn.Abort()
+	require.True(ErrIndexIDAlreadyRegistered.Is(err))
+}
+
+func TestRegistryTxnDeleteIndex(t *testing.T) {
+	require := require.New(t)
+
+	r := NewIndexRegistry()
+	idx := &fakeComponentIndex{id: "idx", db: "db", table: "t"}
+
+	txn := r.Txn()
+	require.NoError(txn.AddIndex(idx))
+	txn.Commit()
+
+	txn = r.Txn()
+	txn.TrackChanges()
+	deleted, err := txn.DeleteIndex("db", "idx")
+	require.NoError(err)
+	require.Equal(idx, deleted)
+
+	changes := txn.Commit()
+	require.Equal(ChangeSet{{Before: idx, After: nil, Op: IndexDeleted}}, changes)
+	require.Nil(r.Index("db", "idx"))
+}